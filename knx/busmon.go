@@ -0,0 +1,141 @@
+package knx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TunnelLayer identifies the cEMI tunnel mode requested in a CONNECT_REQUEST's
+// CRI, determining which kind of frames the gateway exchanges over the
+// resulting channel.
+type TunnelLayer uint8
+
+const (
+	// TunnelLayerLink is the default data-link layer tunnel used for normal
+	// group and device communication.
+	TunnelLayerLink TunnelLayer = 0x02
+
+	// TunnelLayerRaw exposes raw frames without data-link layer processing.
+	TunnelLayerRaw TunnelLayer = 0x04
+
+	// TunnelLayerBusmon puts the channel into busmonitor mode: the gateway
+	// streams every frame observed on the bus and rejects outbound writes.
+	TunnelLayerBusmon TunnelLayer = 0x80
+)
+
+// ErrBusmonReadOnly is returned by Send when the client's tunnel was
+// established with TunnelLayerBusmon, which the KNX specification defines as
+// a read-only, passive monitoring mode.
+var ErrBusmonReadOnly = errors.New("knx: busmonitor tunnel does not accept outbound frames")
+
+// BusmonEvent is a single L_Busmon.ind observed on the bus while the tunnel
+// is in TunnelLayerBusmon mode.
+type BusmonEvent struct {
+	// Timestamp is when the client decoded the frame.
+	Timestamp time.Time
+
+	// Status carries the cEMI status byte (timing/error flags reported by
+	// the gateway's bus interface).
+	Status uint8
+
+	// RawFrame is the undecoded cEMI frame as received from the gateway.
+	RawFrame []byte
+
+	// DecodedAPCI is the application-layer payload extracted from RawFrame,
+	// when the frame could be parsed as a standard group communication
+	// telegram.
+	DecodedAPCI []byte
+}
+
+// busmonMessageCode is the cEMI message code for L_Busmon.ind frames.
+const busmonMessageCode = 0x2b
+
+// parseBusmonEvent decodes a raw cEMI frame into a BusmonEvent. It returns an
+// error if frame is not an L_Busmon.ind.
+func parseBusmonEvent(frame []byte) (BusmonEvent, error) {
+	if len(frame) < 2 || frame[0] != busmonMessageCode {
+		return BusmonEvent{}, errors.New("knx: not an L_Busmon.ind frame")
+	}
+
+	infoLen := int(frame[1])
+	if len(frame) < 2+infoLen {
+		return BusmonEvent{}, errors.New("knx: truncated L_Busmon.ind frame")
+	}
+
+	var status uint8
+	if infoLen > 0 {
+		status = frame[2]
+	}
+
+	payload := frame[2+infoLen:]
+
+	event := BusmonEvent{
+		Status:   status,
+		RawFrame: append([]byte(nil), frame...),
+	}
+
+	if len(payload) > 0 {
+		event.DecodedAPCI = append([]byte(nil), payload...)
+	}
+
+	return event, nil
+}
+
+// sendTunnelRequest sends payload as a TunnelRequest carrying *seqNumber,
+// advancing it on success. It refuses to send on a busmonitor tunnel, which
+// the KNX specification defines as a read-only, passive monitoring mode.
+func (conn *connHandle) sendTunnelRequest(ctx context.Context, payload []byte, seqNumber *uint8) error {
+	if conn.config.TunnelLayer == TunnelLayerBusmon {
+		return ErrBusmonReadOnly
+	}
+
+	req := &TunnelRequest{conn.channel, *seqNumber, payload}
+	if err := conn.sendFrame(ctx, req); err != nil {
+		return err
+	}
+
+	*seqNumber++
+
+	return nil
+}
+
+// handleBusmonRequest processes an inbound TUNNELING_REQUEST carrying an
+// L_Busmon.ind frame. It mirrors handleTunnelRequest's channel/ack handling,
+// but decodes the cEMI payload into a BusmonEvent and delivers it on monitor
+// instead of the raw inbound channel, since a busmonitor tunnel never
+// carries group communication frames to be acted on directly.
+func (conn *connHandle) handleBusmonRequest(
+	ctx context.Context,
+	req *TunnelRequest,
+	seqNumber *uint8,
+	monitor chan<- BusmonEvent,
+) error {
+	if req.Channel != conn.channel {
+		return errors.New("knx: busmon request channel mismatch")
+	}
+
+	if req.SeqNumber == *seqNumber {
+		*seqNumber++
+	} else if req.SeqNumber != *seqNumber-1 {
+		return errors.New("knx: busmon request sequence number out of sync")
+	}
+
+	res := &TunnelResponse{conn.channel, req.SeqNumber, 0}
+	if err := conn.sendFrame(ctx, res); err != nil {
+		return err
+	}
+
+	event, err := parseBusmonEvent(req.Payload)
+	if err != nil {
+		return err
+	}
+	event.Timestamp = time.Now()
+
+	select {
+	case monitor <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,110 @@
+package knx
+
+import (
+	"context"
+	"testing"
+)
+
+// TestConnHandle_handleBusmonRequest mirrors
+// TestConnHandle_handleTunnelRequest's InvalidChannel/InvalidSeqNumber/Ok
+// coverage, but for a busmonitor channel: the decoded event must surface on
+// the monitor channel instead of the regular inbound one, and
+// handleBusmonRequest's own out-of-sync check differs from
+// handleTunnelRequest's (it rejects outright instead of acking a
+// retransmission), so that case is named OutOfSync rather than
+// InvalidSeqNumber.
+func TestConnHandle_handleBusmonRequest(t *testing.T) {
+	ctx := context.Background()
+
+	// L_Busmon.ind, no additional info, a single-byte payload.
+	frame := []byte{busmonMessageCode, 0x00, 0xaa}
+
+	t.Run("InvalidChannel", func(t *testing.T) {
+		sock := makeDummySocket()
+		defer sock.Close()
+
+		var seqNumber uint8 = 0
+
+		conn := connHandle{sock, clientConfig, 1, nil}
+		req := &TunnelRequest{2, 0, frame}
+
+		err := conn.handleBusmonRequest(ctx, req, &seqNumber, make(chan BusmonEvent))
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+
+	t.Run("OutOfSync", func(t *testing.T) {
+		sock := makeDummySocket()
+		defer sock.Close()
+
+		const channel uint8 = 1
+
+		// Neither equal to seqNumber (a fresh request) nor seqNumber-1 (a
+		// retransmission of the last request), so handleBusmonRequest must
+		// reject it without ever touching the socket.
+		var seqNumber uint8 = 5
+
+		conn := connHandle{sock, clientConfig, channel, nil}
+		req := &TunnelRequest{channel, 0, frame}
+
+		err := conn.handleBusmonRequest(ctx, req, &seqNumber, make(chan BusmonEvent))
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+
+		if seqNumber != 5 {
+			t.Error("Sequence number was modified")
+		}
+	})
+
+	t.Run("Ok", func(t *testing.T) {
+		sock := makeDummySocket()
+		monitor := make(chan BusmonEvent)
+
+		const (
+			channel       uint8 = 1
+			sendSeqNumber uint8 = 0
+		)
+
+		t.Run("Gateway", func(t *testing.T) {
+			defer sock.Close()
+			t.Parallel()
+
+			gw := gatewayHelper{ctx, sock, t}
+
+			msg := gw.receive()
+			res, ok := msg.(*TunnelResponse)
+			if !ok {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+
+			if res.Channel != channel || res.SeqNumber != sendSeqNumber || res.Status != 0 {
+				t.Error("Unexpected tunnel response")
+			}
+		})
+
+		t.Run("Worker", func(t *testing.T) {
+			t.Parallel()
+
+			var seqNumber uint8 = sendSeqNumber
+
+			conn := connHandle{sock, clientConfig, channel, nil}
+			req := &TunnelRequest{channel, sendSeqNumber, frame}
+
+			err := conn.handleBusmonRequest(ctx, req, &seqNumber, monitor)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			event := <-monitor
+			if len(event.DecodedAPCI) != 1 || event.DecodedAPCI[0] != 0xaa {
+				t.Error("Unexpected decoded APCI")
+			}
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package knx
+
+import "time"
+
+// defaultResendInterval is how long connHandle waits for a response before
+// resending a request, when ClientConfig.ResendInterval is left at zero.
+const defaultResendInterval = 500 * time.Millisecond
+
+// ClientConfig configures timing and optional extensions for a KNXnet/IP
+// tunnelling connection.
+type ClientConfig struct {
+	// ReconnectDelay configures the delay before the first reconnection
+	// attempt after the underlying socket is lost.
+	ReconnectDelay time.Duration
+
+	// ResendInterval configures the delay between resending a request that
+	// has not yet been answered.
+	ResendInterval time.Duration
+
+	// HeartbeatDelay configures the time which has to elapse without any
+	// incoming communication, until a heartbeat is triggered.
+	HeartbeatDelay time.Duration
+
+	// ResponseTimeout configures the timeout for a response.
+	ResponseTimeout time.Duration
+
+	// Secure, when populated, causes connHandle to negotiate a KNXnet/IP
+	// Secure session before the normal CONNECT_REQUEST and to wrap every
+	// frame exchanged afterwards in a SECURE_WRAPPER.
+	Secure SecureConfig
+
+	// TunnelLayer selects the CRI tunnelling layer requested in
+	// CONNECT_REQUEST. It defaults to TunnelLayerLink when left at zero.
+	TunnelLayer TunnelLayer
+
+	// ReconnectBaseDelay is the initial delay a ResilientTunnel waits before
+	// redialling after a failed or lost connection. It doubles after every
+	// unsuccessful attempt, up to ReconnectMaxDelay.
+	ReconnectBaseDelay time.Duration
+
+	// ReconnectMaxDelay caps the exponential backoff delay between redial
+	// attempts.
+	ReconnectMaxDelay time.Duration
+
+	// ReconnectMaxAttempts bounds how many times a ResilientTunnel will
+	// redial before giving up. Zero means retry indefinitely.
+	ReconnectMaxAttempts int
+
+	// ReconnectHook, if set, is called after every dial attempt: with a nil
+	// error on success, or the dial error otherwise. It is invoked from the
+	// supervisor goroutine, so it must not block.
+	ReconnectHook func(attempt int, err error)
+
+	// Trace, if set, receives connection lifecycle events as connHandle
+	// processes them. See ClientTrace.
+	Trace *ClientTrace
+}
+
+// DefaultClientConfig is a reasonable default configuration for connHandle.
+var DefaultClientConfig = ClientConfig{
+	ReconnectDelay:  500 * time.Millisecond,
+	ResendInterval:  500 * time.Millisecond,
+	HeartbeatDelay:  10 * time.Second,
+	ResponseTimeout: 10 * time.Second,
+}
+
+// effectiveResendInterval returns the configured resend interval, falling
+// back to defaultResendInterval when left at zero.
+func (conf ClientConfig) effectiveResendInterval() time.Duration {
+	if conf.ResendInterval <= 0 {
+		return defaultResendInterval
+	}
+
+	return conf.ResendInterval
+}
@@ -7,10 +7,10 @@ import (
 )
 
 var clientConfig = ClientConfig{
-	2 * time.Second,
-	defaultResendInterval,
-	2 * time.Second,
-	2 * time.Second,
+	ReconnectDelay:  2 * time.Second,
+	ResendInterval:  defaultResendInterval,
+	HeartbeatDelay:  2 * time.Second,
+	ResponseTimeout: 2 * time.Second,
 }
 
 func TestConnHandle_RequestConnection(t *testing.T) {
@@ -18,7 +18,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 
 	// Socket was closed before anything could be done.
 	t.Run("SendFails", func (t *testing.T) {
-		conn := connHandle{makeDummySocket(), clientConfig, 0}
+		conn := connHandle{makeDummySocket(), clientConfig, 0, nil}
 		conn.sock.Close()
 
 		err := conn.requestConnection(ctx)
@@ -32,7 +32,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 		sock := makeDummySocket()
 		defer sock.Close()
 
-		conn := connHandle{sock, clientConfig, 0}
+		conn := connHandle{sock, clientConfig, 0, nil}
 
 		ctx, cancel := context.WithCancel(ctx)
 		cancel()
@@ -63,7 +63,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			config := DefaultClientConfig
 			config.ResendInterval = 1
 
-			conn := connHandle{sock, config, 0}
+			conn := connHandle{sock, config, 0, nil}
 
 			err := conn.requestConnection(ctx)
 			if err == nil {
@@ -100,7 +100,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			config := DefaultClientConfig
 			config.ResendInterval = 1
 
-			conn := connHandle{sock, config, 0}
+			conn := connHandle{sock, config, 0, nil}
 
 			err := conn.requestConnection(ctx)
 			if err != nil {
@@ -119,7 +119,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 		sock.closeIn()
 		defer sock.Close()
 
-		conn := connHandle{sock, clientConfig, 0}
+		conn := connHandle{sock, clientConfig, 0, nil}
 
 		err := conn.requestConnection(ctx)
 		if err == nil {
@@ -150,7 +150,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			defer sock.Close()
 			t.Parallel()
 
-			conn := connHandle{sock, clientConfig, 0}
+			conn := connHandle{sock, clientConfig, 0, nil}
 
 			err := conn.requestConnection(ctx)
 			if err != nil {
@@ -194,7 +194,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			config := DefaultClientConfig
 			config.ResendInterval = 1
 
-			conn := connHandle{sock, config, 0}
+			conn := connHandle{sock, config, 0, nil}
 
 			err := conn.requestConnection(ctx)
 			if err != nil {
@@ -224,7 +224,7 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			defer sock.Close()
 			t.Parallel()
 
-			conn := connHandle{sock, clientConfig, 0}
+			conn := connHandle{sock, clientConfig, 0, nil}
 
 			err := conn.requestConnection(ctx)
 			if err != ConnResUnsupportedType {
@@ -232,6 +232,49 @@ func TestConnHandle_RequestConnection(t *testing.T) {
 			}
 		})
 	})
+
+	// A busmonitor tunnel must request TunnelLayerBusmon in the CRI.
+	t.Run("Busmon", func (t *testing.T) {
+		sock := makeDummySocket()
+
+		const channel uint8 = 1
+
+		t.Run("Gateway", func (t *testing.T) {
+			t.Parallel()
+
+			gw := gatewayHelper{ctx, sock, t}
+
+			msg := gw.receive()
+			if req, ok := msg.(*ConnectionRequest); ok {
+				if req.Layer != TunnelLayerBusmon {
+					t.Errorf("Expected CRI layer %#x, got %#x", TunnelLayerBusmon, req.Layer)
+				}
+
+				gw.send(&ConnectionResponse{channel, ConnResOk, req.Control})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func (t *testing.T) {
+			defer sock.Close()
+			t.Parallel()
+
+			config := clientConfig
+			config.TunnelLayer = TunnelLayerBusmon
+
+			conn := connHandle{sock, config, 0, nil}
+
+			err := conn.requestConnection(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if conn.channel != channel {
+				t.Error("Mismatching channel")
+			}
+		})
+	})
 }
 
 func TestConnHandle_requestConnectionState(t *testing.T) {
@@ -241,7 +284,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 		sock := makeDummySocket()
 		sock.Close()
 
-		conn := connHandle{sock, clientConfig, 1}
+		conn := connHandle{sock, clientConfig, 1, nil}
 
 		err := conn.requestConnectionState(ctx, make(chan ConnState))
 		if err == nil {
@@ -253,7 +296,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 		sock := makeDummySocket()
 		defer sock.Close()
 
-		conn := connHandle{sock, clientConfig, 1}
+		conn := connHandle{sock, clientConfig, 1, nil}
 
 		ctx, cancel := context.WithCancel(ctx)
 		cancel()
@@ -283,7 +326,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 			config := DefaultClientConfig
 			config.ResendInterval = 1
 
-			conn := connHandle{sock, config, 1}
+			conn := connHandle{sock, config, 1, nil}
 
 			err := conn.requestConnectionState(ctx, make(chan ConnState))
 			if err == nil {
@@ -328,7 +371,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 			config := DefaultClientConfig
 			config.ResendInterval = 1
 
-			conn := connHandle{sock, config, channel}
+			conn := connHandle{sock, config, channel, nil}
 
 			err := conn.requestConnectionState(ctx, heartbeat)
 			if err != nil {
@@ -343,7 +386,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 		heartbeat := make(chan ConnState)
 		close(heartbeat)
 
-		conn := connHandle{sock, clientConfig, 1}
+		conn := connHandle{sock, clientConfig, 1, nil}
 
 		err := conn.requestConnectionState(ctx, heartbeat)
 		if err == nil {
@@ -382,7 +425,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 			defer sock.Close()
 			t.Parallel()
 
-			conn := connHandle{sock, clientConfig, channel}
+			conn := connHandle{sock, clientConfig, channel, nil}
 
 			err := conn.requestConnectionState(ctx, heartbeat)
 			if err != nil {
@@ -422,7 +465,7 @@ func TestConnHandle_requestConnectionState(t *testing.T) {
 			defer sock.Close()
 			t.Parallel()
 
-			conn := connHandle{sock, clientConfig, channel}
+			conn := connHandle{sock, clientConfig, channel, nil}
 
 			err := conn.requestConnectionState(ctx, heartbeat)
 			if err != ConnStateInactive {
@@ -441,7 +484,7 @@ func TestConnHandle_handleTunnelRequest(t *testing.T) {
 
 		var seqNumber uint8 = 0
 
-		conn := connHandle{sock, clientConfig, 1}
+		conn := connHandle{sock, clientConfig, 1, nil}
 		req := &TunnelRequest{2, 0, []byte{}}
 
 		err := conn.handleTunnelRequest(ctx, req, &seqNumber, make(chan []byte))
@@ -487,7 +530,7 @@ func TestConnHandle_handleTunnelRequest(t *testing.T) {
 
 			var seqNumber uint8 = sendSeqNumber + 1
 
-			conn := connHandle{sock, clientConfig, channel}
+			conn := connHandle{sock, clientConfig, channel, nil}
 			req := &TunnelRequest{channel, sendSeqNumber, []byte{}}
 
 			err := conn.handleTunnelRequest(ctx, req, &seqNumber, make(chan []byte))
@@ -539,7 +582,7 @@ func TestConnHandle_handleTunnelRequest(t *testing.T) {
 
 			var seqNumber uint8 = sendSeqNumber
 
-			conn := connHandle{sock, clientConfig, channel}
+			conn := connHandle{sock, clientConfig, channel, nil}
 			req := &TunnelRequest{channel, sendSeqNumber, []byte{}}
 
 			err := conn.handleTunnelRequest(ctx, req, &seqNumber, inbound)
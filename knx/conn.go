@@ -0,0 +1,278 @@
+package knx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Socket is the transport a connHandle speaks over: decoded KNXnet/IP
+// messages out, decoded KNXnet/IP messages in.
+type Socket interface {
+	// Send transmits msg to the gateway.
+	Send(msg interface{}) error
+
+	// Inbound yields every message received from the gateway. It is closed
+	// once the socket can no longer deliver anything.
+	Inbound() <-chan interface{}
+
+	// Close releases the underlying transport.
+	Close() error
+}
+
+// connHandle manages the client side of a single KNXnet/IP tunnelling
+// connection: establishing it, keeping it alive with heartbeats, and
+// processing inbound tunnel requests.
+type connHandle struct {
+	sock    Socket
+	config  ClientConfig
+	channel uint8
+
+	// secure holds the negotiated KNXnet/IP Secure session, if any. It is
+	// nil for a plain tunnel.
+	secure *secureSession
+}
+
+// sendCtx sends msg over sock, but gives up with ctx.Err() if ctx is done
+// before the send completes. This matters because Socket.Send can block
+// indefinitely (e.g. an unbuffered dummy socket with nobody reading).
+func sendCtx(ctx context.Context, sock Socket, msg interface{}) error {
+	result := make(chan error, 1)
+
+	go func() {
+		result <- sock.Send(msg)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendAsync starts sending msg over the tunnel in the background and
+// delivers the outcome on done once it completes. Unlike sendFrame, it never
+// blocks the caller, which matters in a resend loop: the loop must keep
+// servicing conn.sock.Inbound() even while a previous send is still stuck in
+// a full outbound buffer, or a slow/blocked write would stall message
+// processing entirely.
+func (conn *connHandle) sendAsync(ctx context.Context, msg interface{}, done chan<- error) {
+	go func() {
+		done <- conn.sendFrame(ctx, msg)
+	}()
+}
+
+// requestConnection sends a ConnectionRequest and resends it on
+// ResendInterval until the gateway answers, ctx is done, or the inbound
+// channel closes. If conf.Secure is populated, it first negotiates a
+// KNXnet/IP Secure session and wraps the ConnectionRequest (and everything
+// that follows) in a SECURE_WRAPPER. On success, conn.channel is set to the
+// channel granted by the gateway.
+func (conn *connHandle) requestConnection(ctx context.Context) error {
+	if conn.config.Secure.enabled() {
+		session, err := negotiateSecureSession(ctx, conn.sock, conn.config.Secure)
+		if err != nil {
+			return err
+		}
+
+		conn.secure = session
+	}
+
+	layer := conn.config.TunnelLayer
+	if layer == 0 {
+		layer = TunnelLayerLink
+	}
+
+	req := &ConnectionRequest{Layer: layer}
+
+	sent := make(chan error, 1)
+	inFlight := true
+	conn.sendAsync(ctx, req, sent)
+	conn.config.Trace.connectRequestSent()
+
+	ticker := time.NewTicker(conn.config.effectiveResendInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-sent:
+			inFlight = false
+
+			if err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			// Skip this resend if the previous one hasn't completed yet,
+			// so a slow socket can't pile up an unbounded number of
+			// in-flight sends.
+			if !inFlight {
+				inFlight = true
+				conn.sendAsync(ctx, req, sent)
+				conn.config.Trace.connectRequestSent()
+			}
+
+		case msg, open := <-conn.sock.Inbound():
+			if !open {
+				return errors.New("knx: inbound channel closed while requesting connection")
+			}
+
+			decoded, err := conn.decodeInbound(msg)
+			if err != nil {
+				continue
+			}
+
+			res, ok := decoded.(*ConnectionResponse)
+			if !ok {
+				continue
+			}
+
+			conn.config.Trace.connectResponseReceived(res.Status, res.Channel)
+
+			if res.Status == ConnResOk {
+				conn.channel = res.Channel
+				return nil
+			}
+
+			if res.Status != ConnResBusy {
+				return res.Status
+			}
+		}
+	}
+}
+
+// sendFrame sends msg over the tunnel, transparently wrapping it in a
+// SECURE_WRAPPER first if a secure session has been negotiated.
+func (conn *connHandle) sendFrame(ctx context.Context, msg interface{}) error {
+	if conn.secure == nil {
+		return sendCtx(ctx, conn.sock, msg)
+	}
+
+	wrapper, err := conn.secure.wrap(msg)
+	if err != nil {
+		return err
+	}
+
+	return sendCtx(ctx, conn.sock, wrapper)
+}
+
+// decodeInbound unwraps msg if it arrived inside a SECURE_WRAPPER and a
+// secure session is active; otherwise it is returned unchanged.
+func (conn *connHandle) decodeInbound(msg interface{}) (interface{}, error) {
+	wrapper, ok := msg.(*SecureWrapper)
+	if !ok || conn.secure == nil {
+		return msg, nil
+	}
+
+	return conn.secure.unwrap(wrapper)
+}
+
+// requestConnectionState sends a ConnectionStateRequest and resends it on
+// ResendInterval until heartbeat yields a ConnState, ctx is done, or
+// heartbeat closes. The ConnState itself arrives via heartbeat rather than
+// conn.sock.Inbound() because decoding ConnectionStateResponse frames is the
+// job of the connection's main read loop, which forwards the result here.
+func (conn *connHandle) requestConnectionState(ctx context.Context, heartbeat <-chan ConnState) error {
+	req := &ConnectionStateRequest{Channel: conn.channel, Status: 0}
+
+	sent := make(chan error, 1)
+	inFlight := true
+	conn.sendAsync(ctx, req, sent)
+	conn.config.Trace.heartbeatSent()
+
+	ticker := time.NewTicker(conn.config.effectiveResendInterval())
+	defer ticker.Stop()
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-sent:
+			inFlight = false
+
+			if err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			// Skip this resend if the previous one hasn't completed yet,
+			// so a slow socket can't pile up an unbounded number of
+			// in-flight sends.
+			if !inFlight {
+				inFlight = true
+				conn.sendAsync(ctx, req, sent)
+				conn.config.Trace.heartbeatSent()
+				conn.config.Trace.tunnelResendScheduled(req.Channel, attempt)
+			}
+
+		case state, open := <-heartbeat:
+			if !open {
+				return errors.New("knx: heartbeat channel closed while requesting connection state")
+			}
+
+			conn.config.Trace.heartbeatResponse(state)
+
+			if state == ConnStateNormal {
+				return nil
+			}
+
+			return state
+		}
+	}
+}
+
+// disconnect notifies the gateway that the channel is being torn down. It is
+// best-effort: the gateway frees the channel on its own timeout even if this
+// never arrives, so a send failure here is only reported through the trace,
+// not returned.
+func (conn *connHandle) disconnect(ctx context.Context, reason error) {
+	conn.config.Trace.disconnectRequested(reason)
+
+	req := &DisconnectRequest{Channel: conn.channel}
+	conn.sendFrame(ctx, req)
+}
+
+// handleTunnelRequest acknowledges an inbound TunnelRequest and, if its
+// sequence number matches the expected one, delivers its payload on inbound
+// and advances seqNumber. A request whose sequence number doesn't match the
+// expected one is treated as a retransmission: it is still acknowledged, but
+// neither delivered nor allowed to advance seqNumber.
+func (conn *connHandle) handleTunnelRequest(
+	ctx context.Context,
+	req *TunnelRequest,
+	seqNumber *uint8,
+	inbound chan<- []byte,
+) error {
+	if req.Channel != conn.channel {
+		return errors.New("knx: tunnel request channel mismatch")
+	}
+
+	conn.config.Trace.tunnelRequestReceived(req.SeqNumber, req.Payload)
+
+	res := &TunnelResponse{conn.channel, req.SeqNumber, 0}
+	if err := conn.sendFrame(ctx, res); err != nil {
+		return err
+	}
+
+	conn.config.Trace.tunnelAckSent()
+
+	if req.SeqNumber != *seqNumber {
+		return nil
+	}
+
+	*seqNumber++
+
+	select {
+	case inbound <- req.Payload:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
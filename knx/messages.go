@@ -0,0 +1,99 @@
+package knx
+
+import "fmt"
+
+// HostInfo identifies an endpoint (IP + port) inside a KNXnet/IP frame's HPAI
+// structure. It is kept deliberately small here; nothing in this package
+// currently inspects its contents beyond echoing it back to the gateway.
+type HostInfo struct {
+	Addr [4]byte
+	Port uint16
+}
+
+// ConnResStatus is the status byte carried by a CONNECT_RESPONSE or
+// CONNECTIONSTATE_RESPONSE frame. It implements error so it can be returned
+// directly by connHandle's request methods.
+type ConnResStatus uint8
+
+// Possible values for ConnResStatus.
+const (
+	ConnResOk              ConnResStatus = 0x00
+	ConnResUnsupportedType ConnResStatus = 0x22
+	ConnResBusy            ConnResStatus = 0x24
+)
+
+func (status ConnResStatus) Error() string {
+	return fmt.Sprintf("knx: connection response status %#02x", uint8(status))
+}
+
+// ConnState is the status reported by a CONNECTIONSTATE_RESPONSE. It
+// implements error so it can be returned directly by
+// connHandle.requestConnectionState.
+type ConnState uint8
+
+// Possible values for ConnState.
+const (
+	ConnStateNormal   ConnState = 0x00
+	ConnStateInactive ConnState = 0x21
+)
+
+func (state ConnState) Error() string {
+	return fmt.Sprintf("knx: connection state %#02x", uint8(state))
+}
+
+// ConnectionRequest is sent to open a new tunnelling channel.
+type ConnectionRequest struct {
+	// Layer selects the cEMI tunnel mode requested for the CRI (see
+	// TunnelLayer).
+	Layer TunnelLayer
+
+	// Control is echoed back unchanged in the matching ConnectionResponse.
+	Control HostInfo
+}
+
+// ConnectionResponse answers a ConnectionRequest.
+type ConnectionResponse struct {
+	Channel uint8
+	Status  ConnResStatus
+	Control HostInfo
+}
+
+// ConnectionStateRequest is a heartbeat sent periodically to keep a channel
+// alive.
+type ConnectionStateRequest struct {
+	Channel uint8
+	Status  uint8
+}
+
+// ConnectionStateResponse answers a ConnectionStateRequest.
+type ConnectionStateResponse struct {
+	Channel uint8
+	Status  ConnState
+}
+
+// TunnelRequest carries a single cEMI frame across an established tunnel,
+// identified by a per-direction sequence number.
+type TunnelRequest struct {
+	Channel   uint8
+	SeqNumber uint8
+	Payload   []byte
+}
+
+// TunnelResponse acknowledges a TunnelRequest.
+type TunnelResponse struct {
+	Channel   uint8
+	SeqNumber uint8
+	Status    uint8
+}
+
+// DisconnectRequest tears down a tunnelling channel.
+type DisconnectRequest struct {
+	Channel uint8
+	Control HostInfo
+}
+
+// DisconnectResponse answers a DisconnectRequest.
+type DisconnectResponse struct {
+	Channel uint8
+	Status  uint8
+}
@@ -0,0 +1,284 @@
+package knx
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultReconnectBaseDelay and defaultReconnectMaxDelay match the backoff
+// parameters used elsewhere in the package for resends.
+const (
+	defaultReconnectBaseDelay = 500 * time.Millisecond
+	defaultReconnectMaxDelay  = 30 * time.Second
+)
+
+// ResilientTunnel wraps a tunnel connection and transparently re-establishes
+// it with exponential backoff whenever the underlying connHandle reports
+// ConnStateInactive, the socket closes, or repeated heartbeats go
+// unanswered. Callers see the same Inbound/Send surface across reconnects.
+type ResilientTunnel struct {
+	dial   func(ctx context.Context) (*connHandle, error)
+	config ClientConfig
+
+	inbound chan []byte
+	send    chan resilientSend
+	done    chan struct{}
+
+	mu            sync.Mutex
+	resubscribers []func()
+}
+
+// resilientSend carries an outbound frame from Send to runConnection,
+// together with the channel its outcome is reported back on.
+type resilientSend struct {
+	data   []byte
+	result chan<- error
+}
+
+// NewResilientTunnel dials an initial connection via dial and starts the
+// supervisor goroutine that keeps it (or a replacement) alive until ctx is
+// cancelled, Close is called, or ReconnectMaxAttempts is exhausted.
+//
+// dial is expected to return a connHandle that has already completed
+// requestConnection; ResilientTunnel only owns what happens after that.
+func NewResilientTunnel(ctx context.Context, dial func(ctx context.Context) (*connHandle, error), config ClientConfig) *ResilientTunnel {
+	tunnel := &ResilientTunnel{
+		dial:    dial,
+		config:  config,
+		inbound: make(chan []byte),
+		send:    make(chan resilientSend),
+		done:    make(chan struct{}),
+	}
+
+	go tunnel.supervise(ctx)
+
+	return tunnel
+}
+
+// Inbound returns the channel on which tunnelled frames are delivered across
+// however many reconnects it takes to keep them flowing.
+func (tunnel *ResilientTunnel) Inbound() <-chan []byte {
+	return tunnel.inbound
+}
+
+// Send transmits data as a tunnel request over the current connection,
+// transparently retrying across a reconnect if one happens to be in
+// progress. It reports ErrBusmonReadOnly without triggering a reconnect when
+// the tunnel was established with TunnelLayerBusmon.
+func (tunnel *ResilientTunnel) Send(data []byte) error {
+	result := make(chan error, 1)
+
+	select {
+	case tunnel.send <- resilientSend{data, result}:
+	case <-tunnel.done:
+		return errors.New("knx: resilient tunnel is closed")
+	}
+
+	return <-result
+}
+
+// OnReconnect registers fn to run after every successful (re)connect, before
+// any inbound frame is delivered on the new connection. Callers use this to
+// resubmit group-address subscriptions that a torn-down tunnel channel
+// forgot.
+func (tunnel *ResilientTunnel) OnReconnect(fn func()) {
+	tunnel.mu.Lock()
+	tunnel.resubscribers = append(tunnel.resubscribers, fn)
+	tunnel.mu.Unlock()
+}
+
+// Close stops the supervisor and releases the underlying connection.
+func (tunnel *ResilientTunnel) Close() {
+	close(tunnel.done)
+}
+
+// supervise owns the reconnect loop: dial, run the connection until it signals
+// failure, back off, and dial again.
+func (tunnel *ResilientTunnel) supervise(ctx context.Context) {
+	defer close(tunnel.inbound)
+
+	delay := tunnel.config.ReconnectBaseDelay
+	if delay <= 0 {
+		delay = defaultReconnectBaseDelay
+	}
+
+	maxDelay := tunnel.config.ReconnectMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		if tunnel.config.ReconnectMaxAttempts > 0 && attempt >= tunnel.config.ReconnectMaxAttempts {
+			return
+		}
+
+		conn, err := tunnel.dial(ctx)
+		if err != nil {
+			if tunnel.config.ReconnectHook != nil {
+				tunnel.config.ReconnectHook(attempt, err)
+			}
+
+			if !tunnel.backoff(ctx, &delay, maxDelay) {
+				return
+			}
+
+			continue
+		}
+
+		if tunnel.config.ReconnectHook != nil {
+			tunnel.config.ReconnectHook(attempt, nil)
+		}
+
+		delay = tunnel.config.ReconnectBaseDelay
+		if delay <= 0 {
+			delay = defaultReconnectBaseDelay
+		}
+
+		if !tunnel.runConnection(ctx, conn) {
+			return
+		}
+	}
+}
+
+// runConnection owns a single live connection: it resets the per-connection
+// sequence counter to 0, replays every registered OnReconnect subscriber,
+// runs a heartbeat loop alongside, and proxies tunnel frames between the
+// caller and the gateway until something goes wrong. It reports whether the
+// supervisor should dial again.
+func (tunnel *ResilientTunnel) runConnection(parent context.Context, conn *connHandle) bool {
+	reconnect, reason := tunnel.serveConnection(parent, conn)
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	conn.disconnect(disconnectCtx, reason)
+	cancel()
+
+	conn.sock.Close()
+
+	return reconnect
+}
+
+// serveConnection is runConnection's inner loop; it reports both whether the
+// supervisor should dial again and, for tracing, why the connection ended.
+func (tunnel *ResilientTunnel) serveConnection(parent context.Context, conn *connHandle) (bool, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	tunnel.mu.Lock()
+	resubscribers := append([]func(){}, tunnel.resubscribers...)
+	tunnel.mu.Unlock()
+
+	for _, fn := range resubscribers {
+		fn()
+	}
+
+	var outSeqNumber uint8
+	var inSeqNumber uint8
+
+	heartbeatState := make(chan ConnState)
+	heartbeatFailed := make(chan error, 1)
+
+	go tunnel.runHeartbeat(ctx, conn, heartbeatState, heartbeatFailed)
+
+	for {
+		select {
+		case <-parent.Done():
+			return false, parent.Err()
+
+		case <-tunnel.done:
+			return false, errors.New("knx: resilient tunnel closed")
+
+		case err := <-heartbeatFailed:
+			return true, err
+
+		case req := <-tunnel.send:
+			err := conn.sendTunnelRequest(ctx, req.data, &outSeqNumber)
+			req.result <- err
+
+			if err != nil && err != ErrBusmonReadOnly {
+				return true, err
+			}
+
+		case msg, open := <-conn.sock.Inbound():
+			if !open {
+				return true, errors.New("knx: inbound channel closed")
+			}
+
+			decoded, err := conn.decodeInbound(msg)
+			if err != nil {
+				continue
+			}
+
+			switch frame := decoded.(type) {
+			case *TunnelRequest:
+				if err := conn.handleTunnelRequest(ctx, frame, &inSeqNumber, tunnel.inbound); err != nil {
+					return true, err
+				}
+
+			case *ConnectionStateResponse:
+				select {
+				case heartbeatState <- frame.Status:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}
+}
+
+// runHeartbeat periodically calls requestConnectionState. Any error --
+// including the ConnState values requestConnectionState itself returns as
+// errors, such as ConnStateInactive -- is forwarded on failed, which signals
+// runConnection to give up on this connection and let the supervisor dial a
+// fresh one.
+func (tunnel *ResilientTunnel) runHeartbeat(ctx context.Context, conn *connHandle, state chan ConnState, failed chan<- error) {
+	delay := conn.config.HeartbeatDelay
+	if delay <= 0 {
+		delay = DefaultClientConfig.HeartbeatDelay
+	}
+
+	ticker := time.NewTicker(delay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := conn.requestConnectionState(ctx, state); err != nil {
+				select {
+				case failed <- err:
+				case <-ctx.Done():
+				}
+
+				return
+			}
+		}
+	}
+}
+
+// backoff sleeps for the current delay plus or minus up to 20% jitter,
+// doubles the delay for next time (capped at maxDelay), and reports whether
+// the caller should keep retrying.
+func (tunnel *ResilientTunnel) backoff(ctx context.Context, delay *time.Duration, maxDelay time.Duration) bool {
+	span := int64(*delay) * 2 / 5
+	jitter := time.Duration(rand.Int63n(span+1)) - time.Duration(span/2)
+	wait := *delay + jitter
+
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return false
+	case <-tunnel.done:
+		return false
+	}
+
+	*delay = time.Duration(float64(*delay) * 2.0)
+	if *delay > maxDelay {
+		*delay = maxDelay
+	}
+
+	return true
+}
@@ -0,0 +1,142 @@
+package knx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestResilientTunnel_Reconnect drops the first connection's inbound
+// direction right away and checks that the supervisor dials again, and that
+// a TunnelRequest received on the replacement connection is acknowledged and
+// delivered through the same Inbound channel the caller started with.
+func TestResilientTunnel_Reconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultClientConfig
+	config.ReconnectBaseDelay = time.Millisecond
+	config.ReconnectMaxDelay = 10 * time.Millisecond
+
+	const channel uint8 = 1
+
+	socks := []*dummySocket{makeDummySocket(), makeDummySocket()}
+
+	var dials int
+
+	dial := func(ctx context.Context) (*connHandle, error) {
+		sock := socks[dials]
+		dials++
+		return &connHandle{sock, clientConfig, channel, nil}, nil
+	}
+
+	tunnel := NewResilientTunnel(ctx, dial, config)
+	defer tunnel.Close()
+
+	// The first connection's inbound direction closes immediately, as if the
+	// gateway vanished; the supervisor should move on to the second dummy
+	// socket without the caller doing anything.
+	socks[0].closeIn()
+
+	gw := gatewayHelper{ctx, socks[1], t}
+
+	go gw.send(&TunnelRequest{Channel: channel, SeqNumber: 0, Payload: []byte{0x01}})
+
+	select {
+	case data := <-tunnel.Inbound():
+		if len(data) != 1 || data[0] != 0x01 {
+			t.Error("Unexpected inbound frame after reconnect")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for frame from reconnected tunnel")
+	}
+
+	if msg := gw.receive(); msg.(*TunnelResponse).SeqNumber != 0 {
+		t.Error("Reconnected tunnel did not acknowledge the tunnel request")
+	}
+
+	if dials < 2 {
+		t.Error("Expected supervisor to dial a second time")
+	}
+}
+
+// TestResilientTunnel_OnReconnect checks that every registered OnReconnect
+// callback runs again each time the supervisor establishes a fresh
+// connection, so callers can resubmit group-address subscriptions that a
+// torn-down tunnel channel forgot.
+func TestResilientTunnel_OnReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultClientConfig
+	config.ReconnectBaseDelay = time.Millisecond
+	config.ReconnectMaxDelay = 10 * time.Millisecond
+
+	socks := []*dummySocket{makeDummySocket(), makeDummySocket()}
+
+	var dials int
+
+	dial := func(ctx context.Context) (*connHandle, error) {
+		sock := socks[dials]
+		dials++
+		return &connHandle{sock, clientConfig, uint8(dials), nil}, nil
+	}
+
+	tunnel := NewResilientTunnel(ctx, dial, config)
+	defer tunnel.Close()
+
+	calls := make(chan struct{}, 2)
+	tunnel.OnReconnect(func() { calls <- struct{}{} })
+
+	// Whether the initial connect's resubscribe pass happened before or
+	// after OnReconnect registered is a race; drain it if present so it
+	// can't be mistaken for the one triggered by the reconnect below.
+	select {
+	case <-calls:
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	socks[0].closeIn()
+
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for OnReconnect callback after reconnect")
+	}
+}
+
+// TestResilientTunnel_BusmonReadOnly checks that Send reports
+// ErrBusmonReadOnly on a busmonitor tunnel without tearing the connection
+// down for a reconnect.
+func TestResilientTunnel_BusmonReadOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultClientConfig
+	config.ReconnectBaseDelay = time.Millisecond
+	config.ReconnectMaxDelay = 10 * time.Millisecond
+
+	busmonConfig := clientConfig
+	busmonConfig.TunnelLayer = TunnelLayerBusmon
+
+	sock := makeDummySocket()
+	defer sock.Close()
+
+	var dials int
+
+	dial := func(ctx context.Context) (*connHandle, error) {
+		dials++
+		return &connHandle{sock, busmonConfig, 1, nil}, nil
+	}
+
+	tunnel := NewResilientTunnel(ctx, dial, config)
+	defer tunnel.Close()
+
+	if err := tunnel.Send([]byte{0x00}); err != ErrBusmonReadOnly {
+		t.Fatalf("Expected %v, got %v", ErrBusmonReadOnly, err)
+	}
+
+	if dials != 1 {
+		t.Error("Busmon rejection should not have triggered a reconnect")
+	}
+}
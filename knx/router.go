@@ -0,0 +1,355 @@
+package knx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GroupAddr is a 3-level KNX group address (main/middle/sub), as used to
+// address group communication telegrams.
+type GroupAddr uint16
+
+// GroupEvent is a single decoded group communication telegram delivered to a
+// Subscription.
+type GroupEvent struct {
+	Source      GroupAddr
+	Destination GroupAddr
+	Data        []byte
+}
+
+// GroupAddrPattern matches group addresses against a filter such as
+// "1/*/*" or "1/2/3". Each of the three levels is either a literal number or
+// "*" to match anything at that level.
+type GroupAddrPattern string
+
+// Match reports whether addr satisfies the pattern.
+func (pattern GroupAddrPattern) Match(addr GroupAddr) bool {
+	parts := strings.Split(string(pattern), "/")
+	if len(parts) != 3 {
+		return false
+	}
+
+	main := (addr >> 11) & 0x1f
+	middle := (addr >> 8) & 0x7
+	sub := addr & 0xff
+
+	levels := [3]uint16{uint16(main), uint16(middle), uint16(sub)}
+
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil || uint16(n) != levels[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dropPolicy controls what a Subscription's bounded queue does when full.
+type dropPolicy int
+
+const (
+	// DropOldest discards the oldest queued event to make room for a new
+	// one, favoring freshness over completeness.
+	DropOldest dropPolicy = iota
+
+	// DropBlock blocks the router's fan-out goroutine until the subscriber
+	// catches up, favoring completeness over freshness for all
+	// subscribers sharing the router.
+	DropBlock
+)
+
+// SubscriptionConfig configures a single Subscription registered with a
+// Router.
+type SubscriptionConfig struct {
+	// Patterns selects which group addresses are delivered to this
+	// subscription. A nil or empty slice matches every address.
+	Patterns []GroupAddrPattern
+
+	// QueueSize bounds how many undelivered GroupEvents are buffered
+	// before DropPolicy kicks in. Zero defaults to 16.
+	QueueSize int
+
+	// DropPolicy chooses the behavior once the queue is full.
+	DropPolicy dropPolicy
+}
+
+// SubscriptionStats are the per-subscription counters returned by
+// Router.Stats().
+type SubscriptionStats struct {
+	FramesIn      uint64
+	FramesDropped uint64
+	SendErrors    uint64
+}
+
+// Subscription is a single logical client multiplexed over a Router's shared
+// tunnel.
+type Subscription struct {
+	router *Router
+	config SubscriptionConfig
+
+	events chan GroupEvent
+
+	mu    sync.Mutex
+	stats SubscriptionStats
+}
+
+// Inbound returns the channel on which matching GroupEvents are delivered.
+func (sub *Subscription) Inbound() <-chan GroupEvent {
+	return sub.events
+}
+
+// Send transmits data to addr over the Router's shared tunnel, serialized
+// against every other subscription's sends.
+func (sub *Subscription) Send(addr GroupAddr, data []byte) error {
+	err := sub.router.send(addr, data)
+
+	if err != nil {
+		sub.mu.Lock()
+		sub.stats.SendErrors++
+		sub.mu.Unlock()
+	}
+
+	return err
+}
+
+// Close unregisters the subscription from its Router.
+func (sub *Subscription) Close() {
+	sub.router.unsubscribe(sub)
+}
+
+// deliver routes event to this subscription's queue according to its
+// QueueSize/DropPolicy configuration.
+func (sub *Subscription) deliver(event GroupEvent) {
+	sub.mu.Lock()
+	sub.stats.FramesIn++
+	sub.mu.Unlock()
+
+	switch sub.config.DropPolicy {
+	case DropBlock:
+		sub.events <- event
+
+	default: // DropOldest
+		select {
+		case sub.events <- event:
+		default:
+			select {
+			case <-sub.events:
+				sub.mu.Lock()
+				sub.stats.FramesDropped++
+				sub.mu.Unlock()
+			default:
+			}
+
+			select {
+			case sub.events <- event:
+			default:
+			}
+		}
+	}
+}
+
+func (sub *Subscription) matches(addr GroupAddr) bool {
+	if len(sub.config.Patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range sub.config.Patterns {
+		if pattern.Match(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Router owns a single connHandle and fans its inbound stream out to any
+// number of Subscriptions filtered by group address, while serializing their
+// outbound sends onto the one tunnel the gateway granted.
+//
+// This exists because most KNX/IP gateways only offer a handful of
+// concurrent tunneling channels; Router lets many logical clients share one.
+type Router struct {
+	conn *connHandle
+	ctx  context.Context
+
+	rawInbound chan []byte
+
+	mu        sync.Mutex
+	subs      []*Subscription
+	seqNumber uint8
+}
+
+// NewRouter wraps conn, taking ownership of its inbound channel, and starts
+// the read loop that decodes inbound TUNNELING_REQUESTs into GroupEvents and
+// fans them out to subscriptions. The read loop, and every send through the
+// returned Router, run until conn's inbound channel closes or ctx is done.
+func NewRouter(ctx context.Context, conn *connHandle) *Router {
+	router := &Router{conn: conn, ctx: ctx, rawInbound: make(chan []byte, 1)}
+
+	go router.readLoop(ctx)
+
+	return router
+}
+
+// readLoop is the Router's single reader of conn.sock.Inbound(): it decodes
+// each TunnelRequest, hands it to conn.handleTunnelRequest for the
+// ack/sequence/trace handling every other connHandle consumer gets, and
+// dispatches the GroupEvent it carries to every matching subscription.
+func (router *Router) readLoop(ctx context.Context) {
+	var inSeqNumber uint8
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case msg, open := <-router.conn.sock.Inbound():
+			if !open {
+				return
+			}
+
+			decoded, err := router.conn.decodeInbound(msg)
+			if err != nil {
+				continue
+			}
+
+			req, ok := decoded.(*TunnelRequest)
+			if !ok {
+				continue
+			}
+
+			if err := router.conn.handleTunnelRequest(ctx, req, &inSeqNumber, router.rawInbound); err != nil {
+				return
+			}
+
+			select {
+			case payload := <-router.rawInbound:
+				event, err := decodeGroupValueWrite(payload)
+				if err != nil {
+					continue
+				}
+
+				router.dispatch(event)
+
+			default:
+				// Retransmission of an already-delivered request: acked
+				// above, nothing new to dispatch.
+			}
+		}
+	}
+}
+
+// Subscribe registers a new Subscription with the given configuration.
+func (router *Router) Subscribe(config SubscriptionConfig) *Subscription {
+	queueSize := config.QueueSize
+	if queueSize == 0 {
+		queueSize = 16
+	}
+
+	sub := &Subscription{
+		router: router,
+		config: config,
+		events: make(chan GroupEvent, queueSize),
+	}
+
+	router.mu.Lock()
+	router.subs = append(router.subs, sub)
+	router.mu.Unlock()
+
+	return sub
+}
+
+// unsubscribe removes sub from the fan-out list.
+func (router *Router) unsubscribe(sub *Subscription) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for i, s := range router.subs {
+		if s == sub {
+			router.subs = append(router.subs[:i], router.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch fans a single decoded GroupEvent out to every matching
+// subscription. It is called from the Router's read loop as frames arrive
+// over the shared tunnel.
+func (router *Router) dispatch(event GroupEvent) {
+	router.mu.Lock()
+	subs := append([]*Subscription(nil), router.subs...)
+	router.mu.Unlock()
+
+	for _, sub := range subs {
+		if sub.matches(event.Destination) {
+			sub.deliver(event)
+		}
+	}
+}
+
+// send arbitrates outbound sends from every subscription onto the single
+// underlying tunnel: router.mu is held for the whole round trip, so sequence
+// numbers are assigned, and TUNNELING_REQUESTs sent, strictly in the order
+// sends are accepted.
+func (router *Router) send(addr GroupAddr, data []byte) error {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	if router.conn == nil {
+		return errors.New("knx: router has no connection")
+	}
+
+	payload := encodeGroupValueWrite(addr, data)
+
+	return router.conn.sendTunnelRequest(router.ctx, payload, &router.seqNumber)
+}
+
+// Stats returns a snapshot of every subscription's counters, in
+// subscription-registration order.
+func (router *Router) Stats() []SubscriptionStats {
+	router.mu.Lock()
+	subs := append([]*Subscription(nil), router.subs...)
+	router.mu.Unlock()
+
+	stats := make([]SubscriptionStats, len(subs))
+	for i, sub := range subs {
+		sub.mu.Lock()
+		stats[i] = sub.stats
+		sub.mu.Unlock()
+	}
+
+	return stats
+}
+
+// encodeGroupValueWrite lays out a group value write as a TunnelRequest
+// payload: the 2-byte destination group address, big-endian, followed by the
+// raw APDU data. This package doesn't otherwise decode cEMI, so it doesn't
+// carry a source address the way a real L_Data.ind would.
+func encodeGroupValueWrite(addr GroupAddr, data []byte) []byte {
+	payload := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(payload[:2], uint16(addr))
+	copy(payload[2:], data)
+
+	return payload
+}
+
+// decodeGroupValueWrite reverses encodeGroupValueWrite.
+func decodeGroupValueWrite(payload []byte) (GroupEvent, error) {
+	if len(payload) < 2 {
+		return GroupEvent{}, errors.New("knx: truncated group value payload")
+	}
+
+	return GroupEvent{
+		Destination: GroupAddr(binary.BigEndian.Uint16(payload[:2])),
+		Data:        append([]byte(nil), payload[2:]...),
+	}, nil
+}
@@ -0,0 +1,80 @@
+package knx
+
+import "testing"
+
+func TestGroupAddrPattern_Match(t *testing.T) {
+	addr := GroupAddr(1<<11 | 2<<8 | 3)
+
+	cases := []struct {
+		pattern GroupAddrPattern
+		want    bool
+	}{
+		{"1/2/3", true},
+		{"1/*/*", true},
+		{"*/*/*", true},
+		{"1/2/4", false},
+		{"2/*/*", false},
+		{"1/2", false},
+	}
+
+	for _, c := range cases {
+		if got := c.pattern.Match(addr); got != c.want {
+			t.Errorf("%q.Match(%v) = %v, want %v", c.pattern, addr, got, c.want)
+		}
+	}
+}
+
+func TestRouter_DispatchFiltersByPattern(t *testing.T) {
+	router := &Router{}
+
+	matching := router.Subscribe(SubscriptionConfig{Patterns: []GroupAddrPattern{"1/*/*"}})
+	other := router.Subscribe(SubscriptionConfig{Patterns: []GroupAddrPattern{"2/*/*"}})
+
+	addr := GroupAddr(1 << 11)
+	router.dispatch(GroupEvent{Destination: addr, Data: []byte{0x01}})
+
+	select {
+	case event := <-matching.Inbound():
+		if event.Destination != addr {
+			t.Error("Mismatching destination")
+		}
+	default:
+		t.Error("Expected matching subscription to receive the event")
+	}
+
+	select {
+	case <-other.Inbound():
+		t.Error("Non-matching subscription should not receive the event")
+	default:
+	}
+}
+
+func TestSubscription_DropOldest(t *testing.T) {
+	router := &Router{}
+
+	sub := router.Subscribe(SubscriptionConfig{QueueSize: 1, DropPolicy: DropOldest})
+
+	router.dispatch(GroupEvent{Destination: 1, Data: []byte{0x01}})
+	router.dispatch(GroupEvent{Destination: 1, Data: []byte{0x02}})
+
+	event := <-sub.Inbound()
+	if event.Data[0] != 0x02 {
+		t.Error("Expected oldest event to have been dropped")
+	}
+
+	stats := router.Stats()
+	if len(stats) != 1 || stats[0].FramesDropped != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestRouter_Unsubscribe(t *testing.T) {
+	router := &Router{}
+
+	sub := router.Subscribe(SubscriptionConfig{})
+	sub.Close()
+
+	if len(router.Stats()) != 0 {
+		t.Error("Expected subscription to be removed")
+	}
+}
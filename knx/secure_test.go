@@ -0,0 +1,292 @@
+package knx
+
+import (
+	"context"
+	"testing"
+)
+
+// TestNegotiateSecureSession mirrors TestConnHandle_RequestConnection's
+// Ok/CancelledContext/InboundClosed cases, but for the SESSION_REQUEST /
+// SESSION_RESPONSE / SESSION_AUTHENTICATE exchange that precedes a secure
+// tunnel's CONNECT_REQUEST.
+func TestNegotiateSecureSession(t *testing.T) {
+	ctx := context.Background()
+
+	conf := SecureConfig{UserPassword: "secret", UserID: 1}
+
+	t.Run("Ok", func(t *testing.T) {
+		sock := makeDummySocket()
+
+		const sessionID uint16 = 7
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			gw := gatewayHelper{ctx, sock, t}
+
+			msg := gw.receive()
+			req, ok := msg.(*SessionRequest)
+			if !ok {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+
+			gw.send(&SessionResponse{SessionID: sessionID, PublicKey: req.PublicKey})
+
+			msg = gw.receive()
+			if _, ok := msg.(*SessionAuthenticate); !ok {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			defer sock.Close()
+			t.Parallel()
+
+			session, err := negotiateSecureSession(ctx, sock, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if session.id != sessionID {
+				t.Error("Mismatching session ID")
+			}
+		})
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		sock := makeDummySocket()
+		defer sock.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := negotiateSecureSession(ctx, sock, conf)
+		if err != ctx.Err() {
+			t.Fatalf("Expected error %v, got %v", ctx.Err(), err)
+		}
+	})
+
+	t.Run("InboundClosed", func(t *testing.T) {
+		sock := makeDummySocket()
+		sock.closeIn()
+		defer sock.Close()
+
+		_, err := negotiateSecureSession(ctx, sock, conf)
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+}
+
+// TestSecureSession_WrapUnwrap checks that a frame wrapped for one sequence
+// number unwraps to the same plaintext, and that a replayed sequence number
+// is rejected.
+func TestSecureSession_WrapUnwrap(t *testing.T) {
+	session := &secureSession{key: DeriveTestKey()}
+
+	frame := &TunnelRequest{Channel: 1, SeqNumber: 0, Payload: []byte{0x06, 0x10, 0x04, 0x20, 0x00, 0x15}}
+
+	wrapped, err := session.wrap(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unwrapped, err := session.unwrap(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, ok := unwrapped.(*TunnelRequest)
+	if !ok || req.Channel != frame.Channel || string(req.Payload) != string(frame.Payload) {
+		t.Error("Unwrapped frame does not match original")
+	}
+
+	if _, err := session.unwrap(wrapped); err == nil {
+		t.Error("Expected replayed sequence number to be rejected")
+	}
+}
+
+// DeriveTestKey is a small helper producing a deterministic session key for
+// tests that don't need to exercise the full password-based derivation.
+func DeriveTestKey() [16]byte {
+	var key [16]byte
+	copy(key[:], "0123456789abcdef")
+	return key
+}
+
+// secureGatewayUnwrap asserts that msg is a *SecureWrapper, unwraps it with
+// session and returns the decoded frame, failing t otherwise.
+func secureGatewayUnwrap(t *testing.T, session *secureSession, msg interface{}) interface{} {
+	t.Helper()
+
+	wrapper, ok := msg.(*SecureWrapper)
+	if !ok {
+		t.Fatalf("Unexpected incoming message type: %T", msg)
+	}
+
+	frame, err := session.unwrap(wrapper)
+	if err != nil {
+		t.Fatalf("Failed to unwrap SECURE_WRAPPER: %v", err)
+	}
+
+	return frame
+}
+
+// secureGatewaySend wraps frame with session and delivers it through gw, as
+// the gateway side of a secure tunnel would.
+func secureGatewaySend(t *testing.T, gw gatewayHelper, session *secureSession, frame interface{}) {
+	t.Helper()
+
+	wrapped, err := session.wrap(frame)
+	if err != nil {
+		t.Fatalf("Failed to wrap frame: %v", err)
+	}
+
+	gw.send(wrapped)
+}
+
+// TestConnHandle_RequestConnection_Secure mirrors
+// TestConnHandle_RequestConnection's "Ok" case, but with conn.secure
+// populated, so every CONNECT_REQUEST/CONNECT_RESPONSE travels inside a
+// SECURE_WRAPPER instead of as a bare frame.
+func TestConnHandle_RequestConnection_Secure(t *testing.T) {
+	ctx := context.Background()
+	sock := makeDummySocket()
+
+	const channel uint8 = 1
+	key := DeriveTestKey()
+
+	t.Run("Gateway", func(t *testing.T) {
+		t.Parallel()
+
+		session := &secureSession{key: key}
+		gw := gatewayHelper{ctx, sock, t}
+
+		req, ok := secureGatewayUnwrap(t, session, gw.receive()).(*ConnectionRequest)
+		if !ok {
+			t.Fatal("Unexpected decoded frame type")
+		}
+
+		secureGatewaySend(t, gw, session, &ConnectionResponse{channel, ConnResOk, req.Control})
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		defer sock.Close()
+		t.Parallel()
+
+		conn := connHandle{sock, clientConfig, 0, &secureSession{key: key}}
+
+		if err := conn.requestConnection(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if conn.channel != channel {
+			t.Error("Mismatching channel")
+		}
+	})
+}
+
+// TestConnHandle_requestConnectionState_Secure mirrors
+// TestConnHandle_requestConnectionState's "Ok" case with conn.secure
+// populated, so the CONNECTIONSTATE_REQUEST travels inside a SECURE_WRAPPER.
+func TestConnHandle_requestConnectionState_Secure(t *testing.T) {
+	ctx := context.Background()
+	sock := makeDummySocket()
+
+	const channel uint8 = 1
+	key := DeriveTestKey()
+	heartbeat := make(chan ConnState)
+
+	t.Run("Gateway", func(t *testing.T) {
+		t.Parallel()
+
+		session := &secureSession{key: key}
+		gw := gatewayHelper{ctx, sock, t}
+
+		req, ok := secureGatewayUnwrap(t, session, gw.receive()).(*ConnectionStateRequest)
+		if !ok {
+			t.Fatal("Unexpected decoded frame type")
+		}
+
+		if req.Channel != channel {
+			t.Error("Mismatching channel")
+		}
+
+		heartbeat <- ConnStateNormal
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		defer sock.Close()
+		t.Parallel()
+
+		conn := connHandle{sock, clientConfig, channel, &secureSession{key: key}}
+
+		if err := conn.requestConnectionState(ctx, heartbeat); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestConnHandle_handleTunnelRequest_Secure mirrors
+// TestConnHandle_handleTunnelRequest's "Ok" case with conn.secure populated,
+// so the acknowledging TUNNELING_ACK travels inside a SECURE_WRAPPER.
+func TestConnHandle_handleTunnelRequest_Secure(t *testing.T) {
+	ctx := context.Background()
+	sock := makeDummySocket()
+	inbound := make(chan []byte)
+
+	const (
+		channel       uint8 = 1
+		sendSeqNumber uint8 = 0
+	)
+
+	key := DeriveTestKey()
+
+	t.Run("Gateway", func(t *testing.T) {
+		defer sock.Close()
+		t.Parallel()
+
+		session := &secureSession{key: key}
+		gw := gatewayHelper{ctx, sock, t}
+
+		res, ok := secureGatewayUnwrap(t, session, gw.receive()).(*TunnelResponse)
+		if !ok {
+			t.Fatal("Unexpected decoded frame type")
+		}
+
+		if res.Channel != channel {
+			t.Error("Mismatching channel")
+		}
+
+		if res.SeqNumber != sendSeqNumber {
+			t.Error("Mismatching sequence number")
+		}
+
+		if res.Status != 0 {
+			t.Error("Invalid response status")
+		}
+	})
+
+	t.Run("Worker", func(t *testing.T) {
+		t.Parallel()
+
+		seqNumber := sendSeqNumber
+
+		conn := connHandle{sock, clientConfig, channel, &secureSession{key: key}}
+		req := &TunnelRequest{channel, sendSeqNumber, []byte{}}
+
+		if err := conn.handleTunnelRequest(ctx, req, &seqNumber, inbound); err != nil {
+			t.Fatal(err)
+		}
+
+		if seqNumber != sendSeqNumber+1 {
+			t.Error("Sequence number has not been increased")
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		t.Parallel()
+
+		<-inbound
+	})
+}
@@ -0,0 +1,117 @@
+package knx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// dummySocket is an in-memory Socket used by tests. Unlike a real socket, it
+// exchanges decoded messages directly rather than encoded bytes: "in" carries
+// messages a test pretends the gateway sent, "out" carries messages the code
+// under test sent to the (simulated) gateway.
+type dummySocket struct {
+	in  chan interface{}
+	out chan interface{}
+
+	// outDone is closed by closeOut to signal Send that the outbound
+	// direction is gone. Send selects on it instead of ever sending on a
+	// channel that closeOut might close concurrently, which would race.
+	outDone chan struct{}
+
+	closeInOnce  sync.Once
+	closeOutOnce sync.Once
+}
+
+// outBacklog bounds how many outbound messages a dummySocket queues before
+// Send blocks. It only needs to be large enough that a test's Send calls
+// don't have to be synchronized with a "Gateway" goroutine reading them one
+// at a time, mirroring how a real (UDP) socket's Send doesn't wait for the
+// peer to consume anything.
+const outBacklog = 16
+
+// makeDummySocket creates a dummySocket with both directions open.
+func makeDummySocket() *dummySocket {
+	return &dummySocket{
+		in:      make(chan interface{}),
+		out:     make(chan interface{}, outBacklog),
+		outDone: make(chan struct{}),
+	}
+}
+
+// Send implements Socket. Sending on a closed socket reports an error
+// instead of racing closeOut's close(outDone).
+func (sock *dummySocket) Send(msg interface{}) error {
+	select {
+	case sock.out <- msg:
+		return nil
+
+	case <-sock.outDone:
+		return errors.New("knx: socket is closed")
+	}
+}
+
+// Inbound implements Socket.
+func (sock *dummySocket) Inbound() <-chan interface{} {
+	return sock.in
+}
+
+// closeIn closes the inbound direction only, simulating the gateway having
+// hung up while outbound sends still (temporarily) succeed.
+func (sock *dummySocket) closeIn() {
+	sock.closeInOnce.Do(func() { close(sock.in) })
+}
+
+// closeOut closes the outbound direction only, simulating a write failure on
+// the underlying transport.
+func (sock *dummySocket) closeOut() {
+	sock.closeOutOnce.Do(func() { close(sock.outDone) })
+}
+
+// Close implements Socket.
+func (sock *dummySocket) Close() error {
+	sock.closeIn()
+	sock.closeOut()
+	return nil
+}
+
+// gatewayHelper drives the "gateway" side of a dummySocket in tests: it reads
+// whatever the code under test sent and scripts responses back.
+type gatewayHelper struct {
+	ctx  context.Context
+	sock *dummySocket
+	t    *testing.T
+}
+
+// receive blocks until the client sends a message, the test context is done,
+// or the socket's outbound direction is closed.
+func (gw gatewayHelper) receive() interface{} {
+	select {
+	case msg := <-gw.sock.out:
+		return msg
+
+	case <-gw.sock.outDone:
+		gw.t.Fatal("Gateway: outbound direction closed unexpectedly")
+		return nil
+
+	case <-gw.ctx.Done():
+		gw.t.Fatal("Gateway: context done while waiting to receive")
+		return nil
+	}
+}
+
+// ignore discards exactly one message sent by the client, without
+// responding, to simulate a gateway that dropped the first attempt.
+func (gw gatewayHelper) ignore() {
+	gw.receive()
+}
+
+// send delivers msg to the client as if the gateway had sent it.
+func (gw gatewayHelper) send(msg interface{}) {
+	select {
+	case gw.sock.in <- msg:
+	case <-gw.ctx.Done():
+		gw.t.Fatal("Gateway: context done while sending")
+	}
+}
@@ -0,0 +1,94 @@
+package knx
+
+// ClientTrace holds a set of optional callbacks for observing tunnel
+// lifecycle events, in the spirit of net/http/httptrace. Any field may be
+// left nil; connHandle only invokes the callbacks that are set.
+//
+// None of the callbacks may block or call back into the connHandle that
+// invoked them — they run synchronously on the connection's goroutine.
+type ClientTrace struct {
+	// ConnectRequestSent fires right after a CONNECT_REQUEST has been
+	// written to the socket.
+	ConnectRequestSent func()
+
+	// ConnectResponseReceived fires once a CONNECT_RESPONSE has been parsed,
+	// whether or not the gateway accepted the connection.
+	ConnectResponseReceived func(status ConnResStatus, channel uint8)
+
+	// HeartbeatSent fires right after a CONNECTIONSTATE_REQUEST has been
+	// written to the socket.
+	HeartbeatSent func()
+
+	// HeartbeatResponse fires once a CONNECTIONSTATE_RESPONSE has been
+	// parsed.
+	HeartbeatResponse func(state ConnState)
+
+	// TunnelRequestReceived fires for every inbound TUNNELING_REQUEST,
+	// before it is acknowledged or delivered.
+	TunnelRequestReceived func(seq uint8, apdu []byte)
+
+	// TunnelAckSent fires right after a TUNNELING_ACK has been written to
+	// the socket in response to a TUNNELING_REQUEST.
+	TunnelAckSent func()
+
+	// TunnelResendScheduled fires whenever a TUNNELING_REQUEST or
+	// CONNECTIONSTATE_REQUEST is about to be resent after not being
+	// acknowledged in time.
+	TunnelResendScheduled func(seq uint8, attempt int)
+
+	// DisconnectRequested fires when the client decides to tear down the
+	// connection, whether by explicit call or because of an error.
+	DisconnectRequested func(reason error)
+}
+
+// firing helpers below let call sites guard a nil ClientConfig.Trace with a
+// single nil check instead of repeating "if conn.config.Trace != nil &&
+// conn.config.Trace.X != nil" everywhere a hook might fire.
+
+func (trace *ClientTrace) connectRequestSent() {
+	if trace != nil && trace.ConnectRequestSent != nil {
+		trace.ConnectRequestSent()
+	}
+}
+
+func (trace *ClientTrace) connectResponseReceived(status ConnResStatus, channel uint8) {
+	if trace != nil && trace.ConnectResponseReceived != nil {
+		trace.ConnectResponseReceived(status, channel)
+	}
+}
+
+func (trace *ClientTrace) heartbeatSent() {
+	if trace != nil && trace.HeartbeatSent != nil {
+		trace.HeartbeatSent()
+	}
+}
+
+func (trace *ClientTrace) heartbeatResponse(state ConnState) {
+	if trace != nil && trace.HeartbeatResponse != nil {
+		trace.HeartbeatResponse(state)
+	}
+}
+
+func (trace *ClientTrace) tunnelRequestReceived(seq uint8, apdu []byte) {
+	if trace != nil && trace.TunnelRequestReceived != nil {
+		trace.TunnelRequestReceived(seq, apdu)
+	}
+}
+
+func (trace *ClientTrace) tunnelAckSent() {
+	if trace != nil && trace.TunnelAckSent != nil {
+		trace.TunnelAckSent()
+	}
+}
+
+func (trace *ClientTrace) tunnelResendScheduled(seq uint8, attempt int) {
+	if trace != nil && trace.TunnelResendScheduled != nil {
+		trace.TunnelResendScheduled(seq, attempt)
+	}
+}
+
+func (trace *ClientTrace) disconnectRequested(reason error) {
+	if trace != nil && trace.DisconnectRequested != nil {
+		trace.DisconnectRequested(reason)
+	}
+}
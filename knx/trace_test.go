@@ -0,0 +1,134 @@
+package knx
+
+import (
+	"context"
+	"testing"
+)
+
+// TestClientTrace_RequestConnection checks that ConnectRequestSent and
+// ConnectResponseReceived fire, in order, around a successful
+// requestConnection call.
+func TestClientTrace_RequestConnection(t *testing.T) {
+	ctx := context.Background()
+	sock := makeDummySocket()
+
+	const channel uint8 = 1
+
+	var events []string
+
+	trace := &ClientTrace{
+		ConnectRequestSent: func() {
+			events = append(events, "sent")
+		},
+		ConnectResponseReceived: func(status ConnResStatus, gotChannel uint8) {
+			events = append(events, "received")
+
+			if status != ConnResOk {
+				t.Errorf("Unexpected status: %v", status)
+			}
+
+			if gotChannel != channel {
+				t.Error("Mismatching channel")
+			}
+		},
+	}
+
+	config := clientConfig
+	config.Trace = trace
+
+	t.Run("Exchange", func(t *testing.T) {
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			gw := gatewayHelper{ctx, sock, t}
+
+			msg := gw.receive()
+			if req, ok := msg.(*ConnectionRequest); ok {
+				gw.send(&ConnectionResponse{channel, ConnResOk, req.Control})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			defer sock.Close()
+			t.Parallel()
+
+			conn := connHandle{sock, config, 0, nil}
+
+			if err := conn.requestConnection(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+
+	// Exchange only returns once both of its parallel subtests have
+	// completed, so events is fully populated by the time Verify runs.
+	t.Run("Verify", func(t *testing.T) {
+		if len(events) != 2 || events[0] != "sent" || events[1] != "received" {
+			t.Errorf("Unexpected trace event order: %v", events)
+		}
+	})
+}
+
+// TestClientTrace_HeartbeatResponse checks that HeartbeatSent and
+// HeartbeatResponse fire around a successful requestConnectionState call.
+func TestClientTrace_HeartbeatResponse(t *testing.T) {
+	ctx := context.Background()
+	sock := makeDummySocket()
+
+	const channel uint8 = 1
+	heartbeat := make(chan ConnState)
+
+	var events []string
+
+	trace := &ClientTrace{
+		HeartbeatSent: func() {
+			events = append(events, "sent")
+		},
+		HeartbeatResponse: func(state ConnState) {
+			events = append(events, "received")
+		},
+	}
+
+	config := clientConfig
+	config.Trace = trace
+
+	t.Run("Exchange", func(t *testing.T) {
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			gw := gatewayHelper{ctx, sock, t}
+
+			msg := gw.receive()
+			if req, ok := msg.(*ConnectionStateRequest); ok {
+				if req.Channel != channel {
+					t.Error("Mismatching channel")
+				}
+
+				heartbeat <- ConnStateNormal
+			} else {
+				t.Fatal("Unexpected type %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			defer sock.Close()
+			t.Parallel()
+
+			conn := connHandle{sock, config, channel, nil}
+
+			if err := conn.requestConnectionState(ctx, heartbeat); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+
+	// Exchange only returns once both of its parallel subtests have
+	// completed, so events is fully populated by the time Verify runs.
+	t.Run("Verify", func(t *testing.T) {
+		if len(events) != 2 || events[0] != "sent" || events[1] != "received" {
+			t.Errorf("Unexpected trace event order: %v", events)
+		}
+	})
+}